@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"range_file/fetcher"
+	"range_file/fetcher/batch"
+)
+
+// BatchOption batch 子命令选项
+type BatchOption struct {
+	FromFile        string
+	FromJson        string
+	Mirror          bool
+	Mtime           string
+	GlobalGoroutine int
+}
+
+var batchOpt BatchOption
+
+// batchCmd 是 download 的子命令,负责批量/递归下载
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "batch download files from a manifest or a directory listing",
+	Long:  `batch download many files, bounding concurrency globally across files and slices`,
+	Run: func(cmd *cobra.Command, args []string) {
+		startBatch()
+	},
+}
+
+func init() {
+	downloadCmd.AddCommand(batchCmd)
+
+	batchCmd.Flags().StringVar(&batchOpt.FromFile, "from-file", "", "每行一个URL的清单文件,可选 \"<url> <sha256> <dest>\" 三元组")
+	batchCmd.Flags().StringVar(&batchOpt.FromJson, "from-json", "", "JSON 格式的清单文件,形如{\"files\":[{\"url\":...}]}")
+	batchCmd.Flags().BoolVar(&batchOpt.Mirror, "mirror", false, "把 --url 当作目录,解析 HTML 索引或 S3 ListObjects 枚举子文件")
+	batchCmd.Flags().StringVar(&batchOpt.Mtime, "mtime", "", "跳过本地已存在且不早于该时长的文件,如 7d、12h")
+	batchCmd.Flags().IntVar(&batchOpt.GlobalGoroutine, "global-g-num", defaultGoroutineNum(), "全局并发上限,跨文件、跨分片共享")
+}
+
+// startBatch 解析清单/目录列表并驱动批量下载
+func startBatch() {
+	entries, err := buildBatchEntries()
+	if err != nil {
+		log.Println("fail to build batch file list:", err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		log.Println("no files to download")
+		return
+	}
+
+	maxAge, err := batch.ParseMtimeFilter(batchOpt.Mtime)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	if err := createDir(Opt.SavePath); err != nil {
+		log.Println("fail to create save path:", err.Error())
+		return
+	}
+	if batchOpt.GlobalGoroutine < minGoroutine || batchOpt.GlobalGoroutine > maxGoroutine*4 {
+		log.Printf("global-g-num must be in range [%d,%d]\n", minGoroutine, maxGoroutine*4)
+		return
+	}
+
+	globalSem := make(chan struct{}, batchOpt.GlobalGoroutine)
+	g := errgroup.Group{}
+	// 入口级别的扇出(Resolve/Create 都在这里发生)也要受全局并发上限约束,
+	// 否则上千个条目会瞬间打出上千个并发 HEAD 请求,只靠分片级别的 globalSem 堵不住
+	g.SetLimit(batchOpt.GlobalGoroutine)
+	for _, entry := range entries {
+		entry := entry
+		g.Go(func() error {
+			if err := fetchBatchEntry(entry, globalSem, maxAge); err != nil {
+				log.Printf("batch download[%s] failed:%s\n", entry.Url, err.Error())
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+// buildBatchEntries 根据 --from-file/--from-json/--mirror 三种来源之一枚举待下载文件
+func buildBatchEntries() ([]batch.Entry, error) {
+	switch {
+	case batchOpt.FromFile != "":
+		return batch.ParseFromFile(batchOpt.FromFile)
+	case batchOpt.FromJson != "":
+		return batch.ParseFromJSON(batchOpt.FromJson)
+	case batchOpt.Mirror && Opt.Url != "":
+		urls, err := batch.ListMirror(Opt.Url)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]batch.Entry, 0, len(urls))
+		for _, u := range urls {
+			entries = append(entries, batch.Entry{Url: u})
+		}
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("batch download requires --from-file, --from-json, or --mirror with --url")
+	}
+}
+
+// fetchBatchEntry 下载清单中的一条记录,复用与单文件下载相同的 fetcher 生命周期,
+// 区别在于把 globalSem 作为跨文件的全局并发令牌桶注入
+func fetchBatchEntry(entry batch.Entry, globalSem chan struct{}, maxAge time.Duration) error {
+	scheme, err := urlScheme(entry.Url)
+	if err != nil {
+		return err
+	}
+	f, err := fetcher.New(scheme)
+	if err != nil {
+		return err
+	}
+
+	req := &fetcher.Request{
+		Url:          entry.Url,
+		SavePath:     Opt.SavePath,
+		FileName:     entry.Dest,
+		PerSize:      Opt.Size * 1024 * 1024,
+		MaxGoroutine: Opt.MaxGoroutine,
+		Resume:       Opt.Resume,
+		Verify:       Opt.Verify,
+		ExpectSHA256: entry.SHA256,
+		MaxBandwidth: Opt.MaxBandwidth,
+		GlobalSem:    globalSem,
+	}
+	res, err := f.Resolve(req)
+	if err != nil {
+		return err
+	}
+
+	localPath := Opt.SavePath + "/" + res.Filename
+	if batch.IsFresh(localPath, maxAge, res.LastModified) {
+		log.Printf("skip fresh file:%s\n", localPath)
+		return nil
+	}
+
+	if err := f.Create(res, req); err != nil {
+		return err
+	}
+	log.Printf("batch download start:%s -> %s\n", entry.Url, localPath)
+	return f.Start(context.Background())
+}
@@ -2,17 +2,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"github.com/spf13/cobra"
-	"io"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
-	"path"
 	"runtime"
-	"sync"
-	"sync/atomic"
 	"time"
+
+	"github.com/spf13/cobra"
+
+	"range_file/fetcher"
+	_ "range_file/fetcher/httpfetcher"
+	"range_file/fetcher/progress"
 )
 
 // DownloadOption 下载选项
@@ -22,30 +24,34 @@ type DownloadOption struct {
 	FileName     string
 	Size         int64
 	MaxGoroutine int
+	Resume       bool
+	Verify       bool
+	Progress     string
+	MetricsAddr  string
+	ExpectSHA256 string
+	ExpectMD5    string
+	MaxBandwidth int64
 }
 
-// DownloadInfo 文件信息
-type DownloadInfo struct {
-	url           string
-	savePath      string
-	totalSize     int64
-	totalParts    int64
-	perSize       int64
-	finishedParts int64
-	canSlice      bool
-	detail        []DownloadSlice
-}
+// minGoroutine/maxGoroutine 限定 --g-num 的合法范围,避免默认值或用户输入压垮服务端
+const (
+	minGoroutine = 1
+	maxGoroutine = 32
+)
 
-// DownloadSlice 下载详情
-type DownloadSlice struct {
-	num    int
-	start  int64
-	end    int64
-	status string
+// defaultGoroutineNum 按 CPU 核数估算默认并发数,并夹在 [minGoroutine, maxGoroutine] 区间内
+func defaultGoroutineNum() int {
+	n := runtime.NumCPU() * 5
+	if n > maxGoroutine {
+		n = maxGoroutine
+	}
+	if n < minGoroutine {
+		n = minGoroutine
+	}
+	return n
 }
 
 var Opt DownloadOption
-var client http.Client
 
 // downloadCmd represents the download command
 var downloadCmd = &cobra.Command{
@@ -58,215 +64,107 @@ var downloadCmd = &cobra.Command{
 }
 
 func init() {
-	client = http.Client{}
 	rootCmd.AddCommand(downloadCmd)
 
-	downloadCmd.Flags().StringVarP(&Opt.Url, "url", "u", "", "下载地址")
-	downloadCmd.Flags().StringVar(&Opt.SavePath, "path", "./downloads", "保存路径")
-	downloadCmd.Flags().StringVar(&Opt.FileName, "name", "", "文件名称")
-	downloadCmd.Flags().Int64Var(&Opt.Size, "size", 1, "分片大小,单位M")
-	downloadCmd.Flags().IntVar(&Opt.MaxGoroutine, "g-num", runtime.NumCPU()*5, "下载启用最大协程数")
+	// 用 PersistentFlags 而非 Flags 注册,这样 batch 等子命令才能继承同一套 --url/--path 等选项,
+	// 而不是各自重新声明一遍(cobra 的 Flags() 不会被子命令继承)
+	downloadCmd.PersistentFlags().StringVarP(&Opt.Url, "url", "u", "", "下载地址")
+	downloadCmd.PersistentFlags().StringVar(&Opt.SavePath, "path", "./downloads", "保存路径")
+	downloadCmd.PersistentFlags().StringVar(&Opt.FileName, "name", "", "文件名称")
+	downloadCmd.PersistentFlags().Int64Var(&Opt.Size, "size", 1, "分片大小,单位M")
+	downloadCmd.PersistentFlags().IntVar(&Opt.MaxGoroutine, "g-num", defaultGoroutineNum(), fmt.Sprintf("下载启用最大协程数,范围[%d,%d]", minGoroutine, maxGoroutine))
+	downloadCmd.PersistentFlags().BoolVar(&Opt.Resume, "resume", true, "是否从断点记录文件(.cpt)恢复下载")
+	downloadCmd.PersistentFlags().BoolVar(&Opt.Verify, "verify", false, "校验模式,只根据断点记录文件重新下载失败的分片")
+	downloadCmd.PersistentFlags().StringVar(&Opt.Progress, "progress", "console", "进度展示方式:console|json|none")
+	downloadCmd.PersistentFlags().StringVar(&Opt.MetricsAddr, "metrics-addr", "", "暴露 Prometheus /metrics 的监听地址,如 :9090,留空则不启用")
+	downloadCmd.PersistentFlags().StringVar(&Opt.ExpectSHA256, "expect-sha256", "", "下载完成后校验的预期 SHA256(hex),不一致则删除文件并返回非零退出码")
+	downloadCmd.PersistentFlags().StringVar(&Opt.ExpectMD5, "expect-md5", "", "下载完成后校验的预期 MD5(hex),不一致则删除文件并返回非零退出码")
+	downloadCmd.PersistentFlags().Int64Var(&Opt.MaxBandwidth, "max-bandwidth", 0, "限速,单位 bytes/sec,0 表示不限速")
 }
 
-// start 启动
+// start 启动:根据 URL 的 scheme 选择对应的 fetcher.Fetcher,驱动 Resolve→Create→Start
 func start() {
-	//fmt.Printf("%+v\n",Opt)
 	if Opt.Url == "" {
 		log.Println("url is empty")
 		return
 	}
-	err := createDir(Opt.SavePath)
-	if err != nil {
-		log.Println("fail to create save path:", err.Error())
+	if Opt.MaxGoroutine < minGoroutine || Opt.MaxGoroutine > maxGoroutine {
+		log.Printf("g-num must be in range [%d,%d], got %d\n", minGoroutine, maxGoroutine, Opt.MaxGoroutine)
 		return
 	}
-	info, err := getDownloadFileInfo(Opt)
-	if err != nil {
-		log.Println("fail to get url info:", err.Error())
+	if err := createDir(Opt.SavePath); err != nil {
+		log.Println("fail to create save path:", err.Error())
 		return
 	}
-	log.Printf("start download file from %s\n save file to:%s\n total size:%d\n total slices:%d\n", info.url, info.savePath, info.totalSize, info.totalParts)
-	//fmt.Printf("%+v\n", info)
-	//return
-	download(info, Opt.MaxGoroutine)
-}
 
-// getDownloadFileInfo 获取下载文件信息
-func getDownloadFileInfo(opt DownloadOption) (info *DownloadInfo, err error) {
-	req, err := http.NewRequest(http.MethodHead, opt.Url, nil)
+	scheme, err := urlScheme(Opt.Url)
 	if err != nil {
+		log.Println("fail to parse url:", err.Error())
 		return
 	}
-	resp, err := client.Do(req)
+	f, err := fetcher.New(scheme)
 	if err != nil {
+		log.Println("fail to build fetcher:", err.Error())
 		return
 	}
-	defer resp.Body.Close()
-	// 获取文件名称
-	if opt.FileName == "" {
-		opt.FileName = path.Base(opt.Url)
-	}
-	fileSize := resp.ContentLength
-	size := opt.Size * 1024 * 1024
-	headerRanges := resp.Header.Get("Accept-Ranges")
-	// 文件大小不合法
-	if fileSize <= 0 {
-		err = fmt.Errorf("wrong file size:%d", fileSize)
-		return nil, err
-	}
-	// 判断是否需要分片下载
-	if headerRanges != "bytes" || fileSize <= size {
-		// 不可分片下载
-		info = &DownloadInfo{
-			url:           opt.Url,
-			savePath:      opt.SavePath + "/" + opt.FileName,
-			totalSize:     fileSize,
-			totalParts:    1,
-			perSize:       size,
-			finishedParts: 0,
-		}
-	} else {
-		// 可以分片下载
-		info = &DownloadInfo{
-			url:           opt.Url,
-			savePath:      opt.SavePath + "/" + opt.FileName,
-			totalSize:     fileSize,
-			totalParts:    (fileSize + size - 1) / size,
-			perSize:       size,
-			finishedParts: 0,
-			canSlice:      true,
-		}
-	}
-	detail := make([]DownloadSlice, 0, info.totalParts)
-	for i := 0; i < int(info.totalParts); i++ {
-		item := DownloadSlice{
-			num:    i + 1,
-			end:    info.perSize * int64(i+1),
-			status: "prepare",
-		}
-		if i == 0 {
-			item.start = 0
-		} else {
-			item.start = info.perSize*int64(i) + 1
-		}
-		detail = append(detail, item)
-	}
-	info.detail = detail
-	return
-}
 
-// download 根据分片数据进行下载
-func download(info *DownloadInfo, maxGoroutine int) {
-	file, err := os.Create(info.savePath)
+	req := &fetcher.Request{
+		Url:          Opt.Url,
+		SavePath:     Opt.SavePath,
+		FileName:     Opt.FileName,
+		PerSize:      Opt.Size * 1024 * 1024,
+		MaxGoroutine: Opt.MaxGoroutine,
+		Resume:       Opt.Resume,
+		Verify:       Opt.Verify,
+		ExpectSHA256: Opt.ExpectSHA256,
+		ExpectMD5:    Opt.ExpectMD5,
+		MaxBandwidth: Opt.MaxBandwidth,
+	}
+	res, err := f.Resolve(req)
 	if err != nil {
+		log.Println("fail to get url info:", err.Error())
 		return
 	}
-	defer file.Close()
-	wg := sync.WaitGroup{}
-	// 切片下载错误标识
-	var errFlag bool
-	var errMsg error
-	wg.Add(int(info.totalParts))
-	downloadChan := make(chan DownloadSlice, Opt.MaxGoroutine)
 
-	go func() {
-		for _, v := range info.detail {
-			item := v
-			downloadChan <- item
-		}
-	}()
-	// 多协程下载文件
-	for i := 0; i < maxGoroutine; i++ {
+	if Opt.Progress != "none" || Opt.MetricsAddr != "" {
+		req.Progress = progress.NewTracker(res.TotalSize, 5*time.Second)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	switch Opt.Progress {
+	case "json":
+		go progress.Run(req.Progress, progress.NewJSONReporter(os.Stdout), time.Second, done)
+	case "none":
+	default:
+		go progress.Run(req.Progress, progress.NewConsoleReporter(os.Stdout), 500*time.Millisecond, done)
+	}
+	if Opt.MetricsAddr != "" {
+		metrics := progress.NewMetricsServer(req.Progress)
 		go func() {
-			for {
-				select {
-				case downloadItem := <-downloadChan:
-
-					tryTimes := 3
-					var downloadErr error
-					// 下载错误,进行重试
-					for i := 1; i <= tryTimes; i++ {
-						downloadErr = downloadSlice(file, info.url, downloadItem, info.canSlice)
-						if err != nil {
-							time.Sleep(time.Millisecond * 100 * time.Duration(i))
-							continue
-						}
-					}
-					if downloadErr != nil {
-						downloadItem.status = "failed"
-						//fmt.Printf("download file[%s][%d] failed:%s\n", info.url, downloadItem.num, err.Error())
-						log.Printf("download file[%s][%d] failed:%s\n", info.url, downloadItem.num, err.Error())
-						errFlag = true
-						errMsg = downloadErr
-					} else {
-						downloadItem.status = "finished"
-						//fmt.Printf("download file[%s][%d] succeed\n", info.url, downloadItem.num)
-						log.Printf("download file[%s][%d] succeed\n", info.url, downloadItem.num)
-					}
-
-					atomic.AddInt64(&info.finishedParts, 1)
-					wg.Done()
-				}
+			if err := metrics.ListenAndServe(Opt.MetricsAddr); err != nil {
+				log.Println("metrics server stopped:", err.Error())
 			}
 		}()
 	}
 
-	wg.Wait()
-	// 出现错误,清理下载文件
-	if errFlag {
-		log.Printf("文件[%s]下载错误:%s\n", info.url, errMsg.Error())
-		if err = os.Remove(info.savePath); err != nil {
-			log.Printf("错误文件[%s]清理失败:%s\n", info.savePath, err.Error())
-		}
-	}
-}
-
-// downloadSlice 分批次下载写入文件
-func downloadSlice(file *os.File, url string, infoSlice DownloadSlice, isSlice bool) (err error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
+	if err := f.Create(res, req); err != nil {
+		log.Println("fail to prepare download:", err.Error())
 		return
 	}
-	if isSlice {
-		req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", infoSlice.start, infoSlice.end))
+	log.Printf("start download file from %s\n save file to:%s/%s\n total size:%d\n", req.Url, req.SavePath, res.Filename, res.TotalSize)
+	if err := f.Start(context.Background()); err != nil {
+		log.Println("download failed:", err.Error())
+		os.Exit(1)
 	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	//fmt.Printf("slice response code:%v,header:%+v\n",resp.StatusCode,resp.Header)
-	expectStatus := http.StatusPartialContent
-	if !isSlice {
-		expectStatus = http.StatusOK
-	}
-	if resp.StatusCode != expectStatus {
-		err = fmt.Errorf("expect status code:%d,but get %d", expectStatus, resp.StatusCode)
-	}
-
-	// 分批写入文件,
-	bufSize := 1024 * 1024
+}
 
-	buf := make([]byte, bufSize)
-	fileOffset := infoSlice.start
-	for {
-		readN, err := resp.Body.Read(buf)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			//fmt.Printf("分批读取内容失败:%s\n", err.Error())
-			return err
-		}
-		//_,err=writer.Write(buf[:readN])
-		_, err = file.WriteAt(buf[:readN], fileOffset)
-		if err != nil {
-			//fmt.Printf("分批写入内容失败:%s\n", err.Error())
-			return err
-		}
-		fileOffset += int64(readN)
+// urlScheme 解析 URL 的 scheme,用于挑选对应协议的 Fetcher
+func urlScheme(rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
 	}
-	return
+	return u.Scheme, nil
 }
 
 // fileExited 文件是否存在
@@ -0,0 +1,114 @@
+// Package batch 驱动多文件的批量/递归下载:从清单文件或目录索引中枚举待下载文件,
+// 再通过一个跨文件共享的全局并发令牌桶把它们交给 fetcher 子系统逐个下载。
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry 是一条待下载任务,Dest 和 SHA256 均可为空
+type Entry struct {
+	Url    string `json:"url"`
+	SHA256 string `json:"sha256,omitempty"`
+	Dest   string `json:"dest,omitempty"`
+}
+
+// manifest 是 --from-json 清单文件的结构
+type manifest struct {
+	Files []Entry `json:"files"`
+}
+
+// ParseFromFile 解析 --from-file:每行一个 URL,或 "<url> <sha256> <dest>" 三元组
+func ParseFromFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		entry := Entry{Url: fields[0]}
+		if len(fields) > 1 {
+			entry.SHA256 = fields[1]
+		}
+		if len(fields) > 2 {
+			entry.Dest = fields[2]
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ParseFromJSON 解析 --from-json:形如 {"files":[{"url":...,"sha256":...,"dest":...}]}
+func ParseFromJSON(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m.Files, nil
+}
+
+// ParseMtimeFilter 解析 find(1) 风格的 --mtime 参数,如 "7d"、"12h"、"30m"
+func ParseMtimeFilter(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	unit := s[len(s)-1:]
+	numPart := s[:len(s)-1]
+	n, err := strconv.Atoi(numPart)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --mtime value %q: %w", s, err)
+	}
+	switch unit {
+	case "d":
+		return time.Duration(n) * 24 * time.Hour, nil
+	case "h":
+		return time.Duration(n) * time.Hour, nil
+	case "m":
+		return time.Duration(n) * time.Minute, nil
+	default:
+		return 0, fmt.Errorf("invalid --mtime unit %q, expect d|h|m suffix", unit)
+	}
+}
+
+// IsFresh 判断本地文件是否足够新,可以跳过重新下载:
+// 要求本地文件存在、不早于 maxAge,并且不比远端 Last-Modified 更旧
+func IsFresh(localPath string, maxAge time.Duration, remoteLastModified string) bool {
+	if maxAge <= 0 {
+		return false
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false
+	}
+	if time.Since(info.ModTime()) > maxAge {
+		return false
+	}
+	if remoteLastModified != "" {
+		remoteTime, err := time.Parse(time.RFC1123, remoteLastModified)
+		if err == nil && remoteTime.After(info.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMtimeFilter(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"empty means no filter", "", 0, false},
+		{"days", "7d", 7 * 24 * time.Hour, false},
+		{"hours", "12h", 12 * time.Hour, false},
+		{"minutes", "30m", 30 * time.Minute, false},
+		{"zero value", "0d", 0, false},
+		{"missing unit", "7", 0, true},
+		{"unknown unit", "7x", 0, true},
+		{"non-numeric", "xxd", 0, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseMtimeFilter(c.input)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMtimeFilter(%q) = nil error, want error", c.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMtimeFilter(%q) unexpected error: %v", c.input, err)
+			}
+			if got != c.want {
+				t.Errorf("ParseMtimeFilter(%q) = %v, want %v", c.input, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsFresh(t *testing.T) {
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "file.bin")
+	if err := os.WriteFile(localPath, []byte("data"), 0644); err != nil {
+		t.Fatalf("seed local file: %v", err)
+	}
+
+	t.Run("maxAge zero always stale", func(t *testing.T) {
+		if IsFresh(localPath, 0, "") {
+			t.Error("IsFresh() = true, want false when maxAge<=0")
+		}
+	})
+
+	t.Run("missing local file is stale", func(t *testing.T) {
+		if IsFresh(filepath.Join(dir, "missing.bin"), time.Hour, "") {
+			t.Error("IsFresh() = true, want false when local file is absent")
+		}
+	})
+
+	t.Run("fresh local file with no remote hint", func(t *testing.T) {
+		if !IsFresh(localPath, time.Hour, "") {
+			t.Error("IsFresh() = false, want true for a recently written file within maxAge")
+		}
+	})
+
+	t.Run("stale because older than maxAge", func(t *testing.T) {
+		old := time.Now().Add(-2 * time.Hour)
+		if err := os.Chtimes(localPath, old, old); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		if IsFresh(localPath, time.Hour, "") {
+			t.Error("IsFresh() = true, want false once local mtime exceeds maxAge")
+		}
+	})
+
+	t.Run("stale because remote is newer", func(t *testing.T) {
+		now := time.Now()
+		if err := os.Chtimes(localPath, now, now); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		remoteNewer := now.Add(time.Hour).UTC().Format(time.RFC1123)
+		if IsFresh(localPath, time.Hour, remoteNewer) {
+			t.Error("IsFresh() = true, want false when remote Last-Modified is newer than local mtime")
+		}
+	})
+
+	t.Run("fresh when remote is older", func(t *testing.T) {
+		now := time.Now()
+		if err := os.Chtimes(localPath, now, now); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		remoteOlder := now.Add(-time.Hour).UTC().Format(time.RFC1123)
+		if !IsFresh(localPath, time.Hour, remoteOlder) {
+			t.Error("IsFresh() = false, want true when remote Last-Modified predates local mtime")
+		}
+	})
+
+	t.Run("unparseable remote header is ignored", func(t *testing.T) {
+		now := time.Now()
+		if err := os.Chtimes(localPath, now, now); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		if !IsFresh(localPath, time.Hour, "not-a-valid-date") {
+			t.Error("IsFresh() = false, want true when remote header fails to parse (ignored)")
+		}
+	})
+}
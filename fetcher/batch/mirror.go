@@ -0,0 +1,88 @@
+package batch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// hrefPattern 粗粒度地从 HTML 目录索引里抠出 <a href="...">,足以覆盖 Apache/Nginx autoindex 风格页面
+var hrefPattern = regexp.MustCompile(`(?i)<a\s+[^>]*href\s*=\s*"([^"]+)"`)
+
+// listBucketResult 是 S3 兼容 ListObjects XML 响应里我们关心的部分
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+// ListMirror 请求 dirUrl,按 Content-Type 区分 HTML 目录索引和 S3 ListObjects XML,
+// 返回枚举出的子文件的绝对 URL 列表
+func ListMirror(dirUrl string) ([]string, error) {
+	resp, err := http.Get(dirUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("expect status code:%d,but get %d", http.StatusOK, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "xml"):
+		return parseS3Listing(body, dirUrl)
+	default:
+		return parseHTMLIndex(body, dirUrl)
+	}
+}
+
+// parseHTMLIndex 从目录索引页面里解析出子项链接,跳过上级目录链接
+func parseHTMLIndex(body []byte, dirUrl string) ([]string, error) {
+	base, err := url.Parse(dirUrl)
+	if err != nil {
+		return nil, err
+	}
+	var children []string
+	for _, m := range hrefPattern.FindAllSubmatch(body, -1) {
+		href := string(m[1])
+		if href == "" || href == "../" || href == "." || strings.HasPrefix(href, "?") {
+			continue
+		}
+		resolved, err := base.Parse(href)
+		if err != nil {
+			continue
+		}
+		children = append(children, resolved.String())
+	}
+	return children, nil
+}
+
+// parseS3Listing 解析 S3 兼容的 ListObjects XML,把每个 Key 拼成绝对 URL
+func parseS3Listing(body []byte, dirUrl string) ([]string, error) {
+	base, err := url.Parse(dirUrl)
+	if err != nil {
+		return nil, err
+	}
+	var result listBucketResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	children := make([]string, 0, len(result.Contents))
+	for _, c := range result.Contents {
+		resolved, err := base.Parse(c.Key)
+		if err != nil {
+			continue
+		}
+		children = append(children, resolved.String())
+	}
+	return children, nil
+}
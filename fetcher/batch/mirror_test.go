@@ -0,0 +1,86 @@
+package batch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHTMLIndex(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		base string
+		want []string
+	}{
+		{
+			name: "typical nginx autoindex",
+			body: `<html><body>
+<a href="../">../</a>
+<a href="a.txt">a.txt</a>
+<a href="sub/b.zip">sub/b.zip</a>
+</body></html>`,
+			base: "http://example.com/dir/",
+			want: []string{"http://example.com/dir/a.txt", "http://example.com/dir/sub/b.zip"},
+		},
+		{
+			name: "skips parent dir and query-only links",
+			body: `<a href="../">Up</a><a href="?C=N;O=D">Name</a><a href=".">self</a>`,
+			base: "http://example.com/dir/",
+			want: nil,
+		},
+		{
+			name: "absolute href preserved",
+			body: `<a href="https://cdn.example.com/file.bin">file.bin</a>`,
+			base: "http://example.com/dir/",
+			want: []string{"https://cdn.example.com/file.bin"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseHTMLIndex([]byte(c.body), c.base)
+			if err != nil {
+				t.Fatalf("parseHTMLIndex() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("parseHTMLIndex() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseS3Listing(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult>
+	<Contents><Key>a.txt</Key></Contents>
+	<Contents><Key>sub/b.zip</Key></Contents>
+</ListBucketResult>`
+
+	got, err := parseS3Listing([]byte(body), "https://bucket.s3.example.com/")
+	if err != nil {
+		t.Fatalf("parseS3Listing() unexpected error: %v", err)
+	}
+	want := []string{
+		"https://bucket.s3.example.com/a.txt",
+		"https://bucket.s3.example.com/sub/b.zip",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseS3Listing() = %v, want %v", got, want)
+	}
+}
+
+func TestParseS3ListingEmpty(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult></ListBucketResult>`
+	got, err := parseS3Listing([]byte(body), "https://bucket.s3.example.com/")
+	if err != nil {
+		t.Fatalf("parseS3Listing() unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("parseS3Listing() = %v, want empty slice", got)
+	}
+}
+
+func TestParseHTMLIndexInvalidBaseURL(t *testing.T) {
+	if _, err := parseHTMLIndex([]byte(`<a href="a.txt">a</a>`), "://bad-url"); err == nil {
+		t.Error("parseHTMLIndex() = nil error, want error for invalid base URL")
+	}
+}
@@ -0,0 +1,99 @@
+// Package fetcher 定义了可插拔的多协议下载器框架:
+// Resolve(探测资源)→Create(规划任务)→Start(下载)→Pause/Continue(暂停恢复)。
+// 具体协议(http、ftp、s3...)实现 Fetcher 接口,并通过 Register 注册到协议名下,
+// 上层只需要根据 URL 的 scheme 选择对应的 Builder 即可,无需关心协议细节。
+package fetcher
+
+import (
+	"context"
+	"fmt"
+
+	"range_file/fetcher/progress"
+)
+
+// Status 下载任务生命周期状态
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusPaused  Status = "paused"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Request 描述一次抓取请求
+type Request struct {
+	Url          string
+	SavePath     string
+	FileName     string
+	PerSize      int64
+	MaxGoroutine int
+	Resume       bool
+	Verify       bool
+	// Progress 可选,非空时下载过程中的字节级进度会上报到此处
+	Progress *progress.Tracker
+	// ExpectSHA256/ExpectMD5 可选,下载完成后与落盘文件的哈希比对,不一致则删除文件并报错
+	ExpectSHA256 string
+	ExpectMD5    string
+	// MaxBandwidth 限速,单位 bytes/sec,0 表示不限速
+	MaxBandwidth int64
+	// GlobalSem 可选,批量下载场景下由调度器注入,用作跨文件、跨分片的全局并发令牌桶
+	GlobalSem chan struct{}
+}
+
+// FileInfo 远端可枚举到的单个文件信息(目录/manifest 场景下可能有多个)
+type FileInfo struct {
+	Name string
+	Size int64
+	Url  string
+}
+
+// Resource 是 Resolve 探测后得到的远端资源描述
+type Resource struct {
+	Range        bool
+	TotalSize    int64
+	Filename     string
+	ETag         string
+	LastModified string
+	// ContentMD5 来自 Content-MD5 响应头(base64),CRC64ECMA 来自 x-oss-hash-crc64ecma 等厂商扩展头,均可能为空
+	ContentMD5 string
+	CRC64ECMA  string
+	Files      []FileInfo
+}
+
+// Fetcher 描述一个可被 Resolve→Create→Start→Pause→Continue 驱动的下载器生命周期
+type Fetcher interface {
+	// Resolve 探测 URL(HEAD 或 Range 0-0),返回远端资源描述
+	Resolve(req *Request) (*Resource, error)
+	// Create 根据 Resolve 得到的资源和请求参数,规划本地下载任务(分片、断点记录等)
+	Create(res *Resource, req *Request) error
+	// Start 启动下载,阻塞直到完成或失败
+	Start(ctx context.Context) error
+	// Pause 暂停下载,可通过 Continue 恢复
+	Pause() error
+	// Continue 从暂停状态恢复下载
+	Continue(ctx context.Context) error
+	// Status 返回当前任务状态
+	Status() Status
+}
+
+// Builder 创建某一类协议的 Fetcher 实例
+type Builder func() Fetcher
+
+var builders = map[string]Builder{}
+
+// Register 注册一个协议的 FetcherBuilder,scheme 如 "http"、"https"、"ftp"、"s3"。
+// 供各协议实现在自己的 init() 中调用。
+func Register(scheme string, builder Builder) {
+	builders[scheme] = builder
+}
+
+// New 根据协议名创建对应的 Fetcher,未注册时返回错误
+func New(scheme string) (Fetcher, error) {
+	builder, ok := builders[scheme]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher registered for scheme:%q", scheme)
+	}
+	return builder(), nil
+}
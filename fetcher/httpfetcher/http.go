@@ -0,0 +1,606 @@
+// Package httpfetcher 是 fetcher.Fetcher 的 HTTP/HTTPS 实现,
+// 使用 Range 请求做分片并发下载,支持基于 .cpt 文件的断点续传。
+package httpfetcher
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"log"
+	"math/rand"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+
+	"range_file/fetcher"
+)
+
+// crc64ECMATable 与阿里云 OSS 等服务商的 x-oss-hash-crc64ecma 头部使用同一多项式
+var crc64ECMATable = crc64.MakeTable(crc64.ECMA)
+
+// readBufSize 是每次从响应体读取的缓冲区大小,同时作为限速器 burst 的下限(单次 WaitN 不会超过它)
+const readBufSize = 1024 * 1024
+
+// statusError 携带响应状态码,用于区分可重试错误(5xx/网络错误)和不可重试的致命错误(4xx)
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected http status code:%d", e.code)
+}
+
+// isFatal 判断错误是否值得立即放弃重试(客户端错误 4xx 重试没有意义)
+func isFatal(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 400 && se.code < 500
+	}
+	return false
+}
+
+// rateLimitedReader 包装 resp.Body,按共享的 rate.Limiter 对读取到的字节数限速
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 && r.limiter != nil {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func init() {
+	fetcher.Register("http", New)
+	fetcher.Register("https", New)
+}
+
+// slice 单个分片的下载状态
+type slice struct {
+	num    int
+	start  int64
+	end    int64
+	status string
+}
+
+// checkpoint 断点续传记录文件(.cpt)的结构
+type checkpoint struct {
+	Url          string            `json:"url"`
+	TotalSize    int64             `json:"total_size"`
+	PerSize      int64             `json:"per_size"`
+	ETag         string            `json:"etag,omitempty"`
+	LastModified string            `json:"last_modified,omitempty"`
+	Slices       []checkpointSlice `json:"slices"`
+}
+
+// checkpointSlice 断点记录中的单个分片状态
+type checkpointSlice struct {
+	Num    int    `json:"num"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Status string `json:"status"`
+}
+
+// Fetcher 是 fetcher.Fetcher 的 HTTP 实现
+type Fetcher struct {
+	client http.Client
+
+	req *fetcher.Request
+	res *fetcher.Resource
+
+	savePath      string
+	canSlice      bool
+	finishedParts int64
+	slices        []slice
+	limiter       *rate.Limiter
+
+	mu     sync.Mutex
+	status fetcher.Status
+	// cancel 是当前 Start 所用 ctx 的取消函数,Pause 调用它来真正中断在途的分片传输
+	cancel context.CancelFunc
+}
+
+// New 创建一个 HTTP Fetcher,满足 fetcher.Builder 签名
+func New() fetcher.Fetcher {
+	return &Fetcher{client: http.Client{}, status: fetcher.StatusPending}
+}
+
+// Resolve 通过 HEAD 探测资源是否支持分片下载及总大小
+func (f *Fetcher) Resolve(req *fetcher.Request) (*fetcher.Resource, error) {
+	httpReq, err := http.NewRequest(http.MethodHead, req.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	fileSize := resp.ContentLength
+	if fileSize <= 0 {
+		return nil, fmt.Errorf("wrong file size:%d", fileSize)
+	}
+	filename := req.FileName
+	if filename == "" {
+		filename = filenameFromContentDisposition(resp.Header.Get("Content-Disposition"))
+	}
+	if filename == "" {
+		filename = path.Base(req.Url)
+	}
+	res := &fetcher.Resource{
+		Range:        resp.Header.Get("Accept-Ranges") == "bytes",
+		TotalSize:    fileSize,
+		Filename:     filename,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentMD5:   resp.Header.Get("Content-MD5"),
+		CRC64ECMA:    resp.Header.Get("x-oss-hash-crc64ecma"),
+	}
+	return res, nil
+}
+
+// filenameFromContentDisposition 解析 Content-Disposition,优先取 RFC 5987 的 filename*,
+// 其次取普通的 filename,都没有则返回空字符串让调用方退回 path.Base(url)
+func filenameFromContentDisposition(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	if name := params["filename*"]; name != "" {
+		return name
+	}
+	return params["filename"]
+}
+
+// Create 根据 Resolve 得到的资源规划分片,并在可恢复的情况下应用断点记录
+func (f *Fetcher) Create(res *fetcher.Resource, req *fetcher.Request) error {
+	f.req = req
+	f.res = res
+	f.savePath = req.SavePath + "/" + res.Filename
+
+	perSize := req.PerSize
+	f.canSlice = res.Range && res.TotalSize > perSize
+	var totalParts int64 = 1
+	if f.canSlice {
+		totalParts = (res.TotalSize + perSize - 1) / perSize
+	}
+
+	slices := make([]slice, 0, totalParts)
+	for i := 0; i < int(totalParts); i++ {
+		item := slice{num: i + 1, end: perSize * int64(i+1), status: "prepare"}
+		if i == 0 {
+			item.start = 0
+		} else {
+			item.start = perSize*int64(i) + 1
+		}
+		slices = append(slices, item)
+	}
+	f.slices = slices
+
+	if req.MaxBandwidth > 0 {
+		burst := readBufSize
+		if req.MaxBandwidth > int64(burst) {
+			burst = int(req.MaxBandwidth)
+		}
+		f.limiter = rate.NewLimiter(rate.Limit(req.MaxBandwidth), burst)
+	}
+
+	if req.Resume || req.Verify {
+		f.applyCheckpoint()
+	}
+	f.status = fetcher.StatusPending
+	return nil
+}
+
+// checkpointPath 断点记录文件路径
+func (f *Fetcher) checkpointPath() string {
+	return f.savePath + ".cpt"
+}
+
+// applyCheckpoint 若存在有效的断点记录且远端校验信息未变化,则用其中记录的全部分片及其状态替换规划结果,
+// 已完成的分片保留 finished 状态,由 Start 跳过
+func (f *Fetcher) applyCheckpoint() {
+	cpt, err := loadCheckpoint(f.checkpointPath())
+	if err != nil {
+		return
+	}
+	if cpt.Url != f.req.Url || cpt.TotalSize != f.res.TotalSize || cpt.PerSize != f.req.PerSize {
+		return
+	}
+	if f.res.ETag != "" && cpt.ETag != f.res.ETag {
+		return
+	}
+	if f.res.LastModified != "" && cpt.LastModified != f.res.LastModified {
+		return
+	}
+	if _, err := os.Stat(f.savePath); err != nil {
+		return
+	}
+	slices := make([]slice, 0, len(cpt.Slices))
+	var finished int64
+	for _, s := range cpt.Slices {
+		item := slice{num: s.Num, start: s.Start, end: s.End, status: s.Status}
+		if f.req.Verify && item.status != "failed" {
+			item.status = "finished"
+		}
+		slices = append(slices, item)
+		if item.status == "finished" {
+			finished++
+		}
+	}
+	f.slices = slices
+	f.finishedParts = finished
+	log.Printf("从断点记录恢复下载,已完成%d/%d个分片\n", finished, len(slices))
+}
+
+// loadCheckpoint 读取断点记录文件
+func loadCheckpoint(cptPath string) (*checkpoint, error) {
+	data, err := os.ReadFile(cptPath)
+	if err != nil {
+		return nil, err
+	}
+	cpt := &checkpoint{}
+	if err := json.Unmarshal(data, cpt); err != nil {
+		return nil, err
+	}
+	return cpt, nil
+}
+
+// saveCheckpoint 原子性写入断点记录文件(先写临时文件再 rename)
+func (f *Fetcher) saveCheckpoint() error {
+	cpt := &checkpoint{
+		Url:          f.req.Url,
+		TotalSize:    f.res.TotalSize,
+		PerSize:      f.req.PerSize,
+		ETag:         f.res.ETag,
+		LastModified: f.res.LastModified,
+		Slices:       make([]checkpointSlice, 0, len(f.slices)),
+	}
+	for _, s := range f.slices {
+		cpt.Slices = append(cpt.Slices, checkpointSlice{Num: s.num, Start: s.start, End: s.end, Status: s.status})
+	}
+	data, err := json.Marshal(cpt)
+	if err != nil {
+		return err
+	}
+	tmpPath := f.checkpointPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, f.checkpointPath())
+}
+
+// Start 启动下载,阻塞直到完成或失败。所有分片共享同一个 errgroup:
+// 任意分片遇到不可重试的致命错误(如 4xx)或耗尽重试次数后,会取消 ctx,其余分片随之中止。
+func (f *Fetcher) Start(ctx context.Context) error {
+	f.mu.Lock()
+	f.status = fetcher.StatusRunning
+	f.mu.Unlock()
+
+	pending := make([]slice, 0, len(f.slices))
+	var resuming bool
+	for _, v := range f.slices {
+		if v.status != "finished" {
+			pending = append(pending, v)
+		} else {
+			resuming = true
+		}
+	}
+
+	// 断点续传时已完成的分片数据还在目标文件里,不能用 O_TRUNC 把它们清零
+	openFlags := os.O_CREATE | os.O_WRONLY
+	if !resuming {
+		openFlags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(f.savePath, openFlags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	f.mu.Lock()
+	f.cancel = cancel
+	f.mu.Unlock()
+	defer cancel()
+
+	g, gctx := errgroup.WithContext(ctx)
+	var cptMutex sync.Mutex
+	downloadChan := make(chan slice, len(pending))
+	for _, v := range pending {
+		downloadChan <- v
+	}
+	close(downloadChan)
+
+	for i := 0; i < f.req.MaxGoroutine; i++ {
+		g.Go(func() error {
+			for {
+				select {
+				case <-gctx.Done():
+					return gctx.Err()
+				case downloadItem, ok := <-downloadChan:
+					if !ok {
+						return nil
+					}
+					if f.req.GlobalSem != nil {
+						select {
+						case f.req.GlobalSem <- struct{}{}:
+						case <-gctx.Done():
+							return gctx.Err()
+						}
+					}
+					downloadErr := f.downloadSliceWithRetry(gctx, file, downloadItem)
+					if f.req.GlobalSem != nil {
+						<-f.req.GlobalSem
+					}
+					// 被 Pause 中断的分片不算失败,保留原状态,留给 Continue 重新下载
+					if downloadErr != nil && errors.Is(downloadErr, context.Canceled) {
+						return downloadErr
+					}
+					if downloadErr != nil {
+						downloadItem.status = "failed"
+						log.Printf("download file[%s][%d] failed:%s\n", f.req.Url, downloadItem.num, downloadErr.Error())
+					} else {
+						downloadItem.status = "finished"
+						log.Printf("download file[%s][%d] succeed\n", f.req.Url, downloadItem.num)
+					}
+
+					cptMutex.Lock()
+					for idx := range f.slices {
+						if f.slices[idx].num == downloadItem.num {
+							f.slices[idx].status = downloadItem.status
+							break
+						}
+					}
+					if f.req.Resume {
+						if err := f.saveCheckpoint(); err != nil {
+							log.Printf("更新断点记录文件失败:%s\n", err.Error())
+						}
+					}
+					cptMutex.Unlock()
+					atomic.AddInt64(&f.finishedParts, 1)
+
+					if downloadErr != nil {
+						return downloadErr
+					}
+				}
+			}
+		})
+	}
+
+	waitErr := g.Wait()
+
+	f.mu.Lock()
+	paused := f.status == fetcher.StatusPaused
+	f.mu.Unlock()
+	if paused {
+		log.Printf("文件[%s]下载已暂停,已完成%d/%d个分片\n", f.req.Url, atomic.LoadInt64(&f.finishedParts), len(f.slices))
+		return nil
+	}
+
+	if waitErr != nil {
+		f.status = fetcher.StatusFailed
+		log.Printf("文件[%s]下载错误:%s\n", f.req.Url, waitErr.Error())
+		if rmErr := os.Remove(f.savePath); rmErr != nil {
+			log.Printf("错误文件[%s]清理失败:%s\n", f.savePath, rmErr.Error())
+		}
+		return waitErr
+	}
+	if err := f.verifyIntegrity(); err != nil {
+		f.status = fetcher.StatusFailed
+		log.Printf("文件[%s]完整性校验失败:%s\n", f.savePath, err.Error())
+		if rmErr := os.Remove(f.savePath); rmErr != nil {
+			log.Printf("错误文件[%s]清理失败:%s\n", f.savePath, rmErr.Error())
+		}
+		return err
+	}
+
+	f.status = fetcher.StatusDone
+	if f.req.Resume {
+		if err := os.Remove(f.checkpointPath()); err != nil && !os.IsNotExist(err) {
+			log.Printf("断点记录文件[%s]清理失败:%s\n", f.checkpointPath(), err.Error())
+		}
+	}
+	return nil
+}
+
+// verifyIntegrity 流式读取落盘文件,逐一校验所有给出的校验信息:--expect-sha256、
+// --expect-md5(未给出时改用 Content-MD5 头)、x-oss-hash-crc64ecma,任一不一致即报错;
+// 没有任何可用的校验信息时直接跳过
+func (f *Fetcher) verifyIntegrity() error {
+	wantSHA256 := strings.ToLower(f.req.ExpectSHA256)
+	wantMD5 := strings.ToLower(f.req.ExpectMD5)
+	wantContentMD5 := f.res.ContentMD5
+	wantCRC64 := f.res.CRC64ECMA
+
+	if wantSHA256 == "" && wantMD5 == "" && wantContentMD5 == "" && wantCRC64 == "" {
+		return nil
+	}
+
+	file, err := os.Open(f.savePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var sha256h hash.Hash
+	var md5h hash.Hash
+	var crc64h hash.Hash64
+	writers := make([]io.Writer, 0, 3)
+	if wantSHA256 != "" {
+		sha256h = sha256.New()
+		writers = append(writers, sha256h)
+	}
+	if wantMD5 != "" || wantContentMD5 != "" {
+		md5h = md5.New()
+		writers = append(writers, md5h)
+	}
+	if wantCRC64 != "" {
+		crc64h = crc64.New(crc64ECMATable)
+		writers = append(writers, crc64h)
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return err
+	}
+
+	if wantSHA256 != "" {
+		if got := hex.EncodeToString(sha256h.Sum(nil)); got != wantSHA256 {
+			return fmt.Errorf("sha256 mismatch: expect %s, got %s", wantSHA256, got)
+		}
+	}
+	if wantMD5 != "" {
+		if got := hex.EncodeToString(md5h.Sum(nil)); got != wantMD5 {
+			return fmt.Errorf("md5 mismatch: expect %s, got %s", wantMD5, got)
+		}
+	} else if wantContentMD5 != "" {
+		if got := base64.StdEncoding.EncodeToString(md5h.Sum(nil)); got != wantContentMD5 {
+			return fmt.Errorf("content-md5 mismatch: expect %s, got %s", wantContentMD5, got)
+		}
+	}
+	if wantCRC64 != "" {
+		if got := strconv.FormatUint(crc64h.Sum64(), 10); got != wantCRC64 {
+			return fmt.Errorf("crc64ecma mismatch: expect %s, got %s", wantCRC64, got)
+		}
+	}
+	return nil
+}
+
+// Pause 暂停下载:取消 Start 里派生的 ctx,使所有在途分片传输立即中止,
+// 未完成的分片状态保持不变,靠 .cpt 断点记录在 Continue 时恢复
+func (f *Fetcher) Pause() error {
+	f.mu.Lock()
+	if f.status != fetcher.StatusRunning {
+		f.mu.Unlock()
+		return fmt.Errorf("fetcher is not running, current status:%s", f.status)
+	}
+	f.status = fetcher.StatusPaused
+	cancel := f.cancel
+	f.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// Continue 从暂停状态恢复下载,依赖 .cpt 断点记录跳过已完成的分片
+func (f *Fetcher) Continue(ctx context.Context) error {
+	f.mu.Lock()
+	if f.status != fetcher.StatusPaused {
+		f.mu.Unlock()
+		return fmt.Errorf("fetcher is not paused, current status:%s", f.status)
+	}
+	f.mu.Unlock()
+	f.applyCheckpoint()
+	return f.Start(ctx)
+}
+
+// Status 返回当前任务状态
+func (f *Fetcher) Status() fetcher.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+// downloadSliceWithRetry 重试下载单个分片,使用基于实际错误的指数退避加抖动;
+// 致命错误(如 4xx)不重试,直接返回
+func (f *Fetcher) downloadSliceWithRetry(ctx context.Context, file *os.File, item slice) error {
+	const maxAttempts = 3
+	var downloadErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		downloadErr = f.downloadSlice(ctx, file, item)
+		if downloadErr == nil {
+			return nil
+		}
+		if isFatal(downloadErr) || attempt == maxAttempts {
+			return downloadErr
+		}
+		backoff := 100 * time.Millisecond * time.Duration(1<<(attempt-1))
+		jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+		select {
+		case <-time.After(backoff + jitter):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return downloadErr
+}
+
+// downloadSlice 按分片范围下载并写入文件对应偏移
+func (f *Fetcher) downloadSlice(ctx context.Context, file *os.File, item slice) error {
+	req, err := http.NewRequest(http.MethodGet, f.req.Url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if f.canSlice {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", item.start, item.end))
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	expectStatus := http.StatusPartialContent
+	if !f.canSlice {
+		expectStatus = http.StatusOK
+	}
+	if resp.StatusCode != expectStatus {
+		return &statusError{code: resp.StatusCode}
+	}
+
+	var body io.Reader = resp.Body
+	if f.limiter != nil {
+		body = &rateLimitedReader{ctx: ctx, r: resp.Body, limiter: f.limiter}
+	}
+
+	sliceTotal := item.end - item.start + 1
+	buf := make([]byte, readBufSize)
+	fileOffset := item.start
+	for {
+		readN, err := body.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := file.WriteAt(buf[:readN], fileOffset); err != nil {
+			return err
+		}
+		fileOffset += int64(readN)
+		if f.req.Progress != nil {
+			f.req.Progress.Add(item.num, int64(readN), sliceTotal)
+		}
+	}
+	return nil
+}
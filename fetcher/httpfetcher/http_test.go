@@ -0,0 +1,275 @@
+package httpfetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"range_file/fetcher"
+)
+
+func TestIsFatal(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"4xx fatal", &statusError{code: 404}, true},
+		{"400 boundary fatal", &statusError{code: 400}, true},
+		{"499 boundary fatal", &statusError{code: 499}, true},
+		{"5xx retryable", &statusError{code: 503}, false},
+		{"500 boundary retryable", &statusError{code: 500}, false},
+		{"3xx retryable", &statusError{code: 301}, false},
+		{"non-status error", errors.New("connection reset"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isFatal(c.err); got != c.want {
+				t.Errorf("isFatal(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+// newTestFetcher 构造一个带最小 req/res 的 Fetcher,用于测试 applyCheckpoint 等依赖磁盘状态的逻辑
+func newTestFetcher(t *testing.T, savePath string) *Fetcher {
+	t.Helper()
+	return &Fetcher{
+		savePath: savePath,
+		req: &fetcher.Request{
+			Url:     "http://example.com/file.bin",
+			PerSize: 1024,
+		},
+		res: &fetcher.Resource{
+			TotalSize: 2048,
+		},
+	}
+}
+
+func writeCheckpoint(t *testing.T, path string, cpt checkpoint) {
+	t.Helper()
+	data, err := json.Marshal(cpt)
+	if err != nil {
+		t.Fatalf("marshal checkpoint: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+}
+
+func TestFilenameFromContentDisposition(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"empty header", "", ""},
+		{"plain filename", `attachment; filename="report.pdf"`, "report.pdf"},
+		{"unquoted filename", `attachment; filename=report.pdf`, "report.pdf"},
+		{"rfc5987 filename* preferred", `attachment; filename="fallback.pdf"; filename*=UTF-8''%e6%8a%a5%e5%91%8a.pdf`, "报告.pdf"},
+		{"malformed header", `;;;`, ""},
+		{"no filename param", `inline`, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := filenameFromContentDisposition(c.header); got != c.want {
+				t.Errorf("filenameFromContentDisposition(%q) = %q, want %q", c.header, got, c.want)
+			}
+		})
+	}
+}
+
+func TestApplyCheckpoint(t *testing.T) {
+	t.Run("resumes when url/size/persize and save file all match", func(t *testing.T) {
+		dir := t.TempDir()
+		savePath := filepath.Join(dir, "file.bin")
+		if err := os.WriteFile(savePath, make([]byte, 2048), 0644); err != nil {
+			t.Fatalf("seed save file: %v", err)
+		}
+		f := newTestFetcher(t, savePath)
+		writeCheckpoint(t, f.checkpointPath(), checkpoint{
+			Url:       f.req.Url,
+			TotalSize: f.res.TotalSize,
+			PerSize:   f.req.PerSize,
+			Slices: []checkpointSlice{
+				{Num: 1, Start: 0, End: 1023, Status: "finished"},
+				{Num: 2, Start: 1024, End: 2047, Status: "prepare"},
+			},
+		})
+
+		f.applyCheckpoint()
+
+		if f.finishedParts != 1 {
+			t.Fatalf("finishedParts = %d, want 1", f.finishedParts)
+		}
+		if len(f.slices) != 2 || f.slices[0].status != "finished" || f.slices[1].status != "prepare" {
+			t.Fatalf("unexpected slices after resume: %+v", f.slices)
+		}
+	})
+
+	t.Run("verify mode promotes non-failed slices to finished", func(t *testing.T) {
+		dir := t.TempDir()
+		savePath := filepath.Join(dir, "file.bin")
+		if err := os.WriteFile(savePath, make([]byte, 2048), 0644); err != nil {
+			t.Fatalf("seed save file: %v", err)
+		}
+		f := newTestFetcher(t, savePath)
+		f.req.Verify = true
+		writeCheckpoint(t, f.checkpointPath(), checkpoint{
+			Url:       f.req.Url,
+			TotalSize: f.res.TotalSize,
+			PerSize:   f.req.PerSize,
+			Slices: []checkpointSlice{
+				{Num: 1, Start: 0, End: 1023, Status: "prepare"},
+				{Num: 2, Start: 1024, End: 2047, Status: "failed"},
+			},
+		})
+
+		f.applyCheckpoint()
+
+		if f.slices[0].status != "finished" {
+			t.Errorf("slice 1 status = %q, want finished", f.slices[0].status)
+		}
+		if f.slices[1].status != "failed" {
+			t.Errorf("slice 2 status = %q, want failed (left for retry)", f.slices[1].status)
+		}
+	})
+
+	t.Run("ignored when save file missing", func(t *testing.T) {
+		dir := t.TempDir()
+		savePath := filepath.Join(dir, "file.bin")
+		f := newTestFetcher(t, savePath)
+		origSlices := f.slices
+		writeCheckpoint(t, f.checkpointPath(), checkpoint{
+			Url:       f.req.Url,
+			TotalSize: f.res.TotalSize,
+			PerSize:   f.req.PerSize,
+			Slices:    []checkpointSlice{{Num: 1, Start: 0, End: 1023, Status: "finished"}},
+		})
+
+		f.applyCheckpoint()
+
+		if f.finishedParts != 0 {
+			t.Errorf("finishedParts = %d, want 0 when save file absent", f.finishedParts)
+		}
+		if len(f.slices) != len(origSlices) {
+			t.Errorf("slices should be left untouched when save file absent")
+		}
+	})
+
+	t.Run("ignored when url changed", func(t *testing.T) {
+		dir := t.TempDir()
+		savePath := filepath.Join(dir, "file.bin")
+		if err := os.WriteFile(savePath, make([]byte, 2048), 0644); err != nil {
+			t.Fatalf("seed save file: %v", err)
+		}
+		f := newTestFetcher(t, savePath)
+		writeCheckpoint(t, f.checkpointPath(), checkpoint{
+			Url:       "http://example.com/other.bin",
+			TotalSize: f.res.TotalSize,
+			PerSize:   f.req.PerSize,
+			Slices:    []checkpointSlice{{Num: 1, Start: 0, End: 1023, Status: "finished"}},
+		})
+
+		f.applyCheckpoint()
+
+		if f.finishedParts != 0 {
+			t.Errorf("finishedParts = %d, want 0 when checkpoint url mismatches", f.finishedParts)
+		}
+	})
+
+	t.Run("ignored when no checkpoint file exists", func(t *testing.T) {
+		dir := t.TempDir()
+		savePath := filepath.Join(dir, "file.bin")
+		f := newTestFetcher(t, savePath)
+		f.applyCheckpoint()
+		if f.finishedParts != 0 {
+			t.Errorf("finishedParts = %d, want 0 without a checkpoint file", f.finishedParts)
+		}
+	})
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	savePath := filepath.Join(dir, "file.bin")
+	content := []byte("hello range_file")
+	if err := os.WriteFile(savePath, content, 0644); err != nil {
+		t.Fatalf("seed save file: %v", err)
+	}
+
+	t.Run("no expectations configured skips verification", func(t *testing.T) {
+		f := newTestFetcher(t, savePath)
+		if err := f.verifyIntegrity(); err != nil {
+			t.Errorf("verifyIntegrity() = %v, want nil when nothing to check", err)
+		}
+	})
+
+	t.Run("matching sha256 passes", func(t *testing.T) {
+		f := newTestFetcher(t, savePath)
+		f.req.ExpectSHA256 = "82acb16f58b92e32da82e8d26afad761193b49c082195cbee904c27d35bfdff0"
+		if err := f.verifyIntegrity(); err != nil {
+			t.Errorf("verifyIntegrity() = %v, want nil for matching sha256", err)
+		}
+	})
+
+	t.Run("mismatched sha256 fails", func(t *testing.T) {
+		f := newTestFetcher(t, savePath)
+		f.req.ExpectSHA256 = "0000000000000000000000000000000000000000000000000000000000000"
+		if err := f.verifyIntegrity(); err == nil {
+			t.Error("verifyIntegrity() = nil, want error for mismatched sha256")
+		}
+	})
+
+	t.Run("mismatched content-md5 fails", func(t *testing.T) {
+		f := newTestFetcher(t, savePath)
+		f.res.ContentMD5 = "not-a-real-checksum=="
+		if err := f.verifyIntegrity(); err == nil {
+			t.Error("verifyIntegrity() = nil, want error for mismatched content-md5")
+		}
+	})
+}
+
+func TestDownloadSliceWithRetry(t *testing.T) {
+	t.Run("network error retries until attempts exhausted", func(t *testing.T) {
+		// port 0 never accepts connections, so every attempt fails with a dial error (not a
+		// statusError), exercising the non-fatal retry-with-backoff path across all 3 attempts.
+		f := &Fetcher{req: &fetcher.Request{Url: "http://127.0.0.1:0"}}
+		tmpFile, err := os.CreateTemp(t.TempDir(), "slice")
+		if err != nil {
+			t.Fatalf("create temp file: %v", err)
+		}
+		defer tmpFile.Close()
+
+		start := time.Now()
+		err = f.downloadSliceWithRetry(context.Background(), tmpFile, slice{num: 1, start: 0, end: 0})
+		elapsed := time.Since(start)
+		if err == nil {
+			t.Fatal("downloadSliceWithRetry() = nil, want error for unreachable host")
+		}
+		// with maxAttempts=3 the backoff between attempt 1->2 alone is >=100ms
+		if elapsed < 100*time.Millisecond {
+			t.Errorf("elapsed = %v, want at least one backoff wait (>=100ms)", elapsed)
+		}
+	})
+
+	t.Run("context cancellation aborts backoff wait", func(t *testing.T) {
+		f := &Fetcher{req: &fetcher.Request{Url: "http://127.0.0.1:0"}}
+		tmpFile, err := os.CreateTemp(t.TempDir(), "slice")
+		if err != nil {
+			t.Fatalf("create temp file: %v", err)
+		}
+		defer tmpFile.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = f.downloadSliceWithRetry(ctx, tmpFile, slice{num: 1, start: 0, end: 0})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("downloadSliceWithRetry() = %v, want context.Canceled", err)
+		}
+	})
+}
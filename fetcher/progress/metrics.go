@@ -0,0 +1,44 @@
+package progress
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// MetricsServer 暴露一个手写的、Prometheus 文本格式的 /metrics 端点,
+// 导出 download_bytes_total、download_slice_status、download_speed_bytes 三个指标。
+type MetricsServer struct {
+	tracker *Tracker
+}
+
+// NewMetricsServer 创建一个从 tracker 读取快照的 MetricsServer
+func NewMetricsServer(tracker *Tracker) *MetricsServer {
+	return &MetricsServer{tracker: tracker}
+}
+
+// ListenAndServe 在 addr 上启动 /metrics 端点,阻塞直到出错
+func (m *MetricsServer) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.handleMetrics)
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleMetrics 按 Prometheus 文本暴露格式渲染当前快照
+func (m *MetricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	snap := m.tracker.Snapshot()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintf(w, "# HELP download_bytes_total Total bytes downloaded so far\n")
+	fmt.Fprintf(w, "# TYPE download_bytes_total gauge\n")
+	fmt.Fprintf(w, "download_bytes_total %d\n", snap.BytesDone)
+
+	fmt.Fprintf(w, "# HELP download_speed_bytes Current moving-average download speed in bytes/sec\n")
+	fmt.Fprintf(w, "# TYPE download_speed_bytes gauge\n")
+	fmt.Fprintf(w, "download_speed_bytes %.2f\n", snap.SpeedBps)
+
+	fmt.Fprintf(w, "# HELP download_slice_status Bytes downloaded per slice\n")
+	fmt.Fprintf(w, "# TYPE download_slice_status gauge\n")
+	for _, s := range snap.Slices {
+		fmt.Fprintf(w, "download_slice_status{slice=\"%d\"} %d\n", s.Num, s.Done)
+	}
+}
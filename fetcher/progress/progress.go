@@ -0,0 +1,185 @@
+// Package progress 收集下载过程中的字节级事件,聚合出吞吐量、移动平均速度和 ETA,
+// 并提供控制台、NDJSON 和 Prometheus 几种上报方式。
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// SliceStatus 单个分片当前的进度状态
+type SliceStatus struct {
+	Num   int   `json:"num"`
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// Snapshot 是某一时刻的聚合进度快照
+type Snapshot struct {
+	BytesDone  int64         `json:"bytes_done"`
+	BytesTotal int64         `json:"bytes_total"`
+	SpeedBps   float64       `json:"speed_bytes_per_sec"`
+	ETA        time.Duration `json:"eta_ns"`
+	Slices     []SliceStatus `json:"slices"`
+}
+
+// sample 用于计算移动平均速度的采样点
+type sample struct {
+	at    time.Time
+	bytes int64
+}
+
+// Tracker 聚合多个分片上报的字节增量,是整个进度子系统的核心
+type Tracker struct {
+	mu         sync.Mutex
+	totalSize  int64
+	bytesDone  int64
+	slices     map[int]*SliceStatus
+	samples    []sample
+	windowSize time.Duration
+}
+
+// NewTracker 创建一个跟踪总大小为 totalSize 的进度聚合器,speedWindow 决定移动平均速度的统计窗口
+func NewTracker(totalSize int64, speedWindow time.Duration) *Tracker {
+	if speedWindow <= 0 {
+		speedWindow = 5 * time.Second
+	}
+	return &Tracker{
+		totalSize:  totalSize,
+		slices:     make(map[int]*SliceStatus),
+		windowSize: speedWindow,
+	}
+}
+
+// Add 记录分片 num 新写入了 n 字节,total 为该分片的总大小
+func (t *Tracker) Add(num int, n int64, total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.slices[num]
+	if !ok {
+		s = &SliceStatus{Num: num, Total: total}
+		t.slices[num] = s
+	}
+	s.Done += n
+	t.bytesDone += n
+	t.samples = append(t.samples, sample{at: time.Now(), bytes: t.bytesDone})
+	t.trimSamplesLocked()
+}
+
+// trimSamplesLocked 丢弃统计窗口之外的旧采样点,调用方需持有 mu
+func (t *Tracker) trimSamplesLocked() {
+	cutoff := time.Now().Add(-t.windowSize)
+	i := 0
+	for i < len(t.samples) && t.samples[i].at.Before(cutoff) {
+		i++
+	}
+	t.samples = t.samples[i:]
+}
+
+// Snapshot 返回当前的聚合进度,包括移动平均速度和预估剩余时间
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var speed float64
+	if len(t.samples) >= 2 {
+		first, last := t.samples[0], t.samples[len(t.samples)-1]
+		elapsed := last.at.Sub(first.at).Seconds()
+		if elapsed > 0 {
+			speed = float64(last.bytes-first.bytes) / elapsed
+		}
+	}
+	var eta time.Duration
+	if speed > 0 && t.totalSize > t.bytesDone {
+		eta = time.Duration(float64(t.totalSize-t.bytesDone)/speed) * time.Second
+	}
+	slices := make([]SliceStatus, 0, len(t.slices))
+	for _, s := range t.slices {
+		slices = append(slices, *s)
+	}
+	return Snapshot{
+		BytesDone:  t.bytesDone,
+		BytesTotal: t.totalSize,
+		SpeedBps:   speed,
+		ETA:        eta,
+		Slices:     slices,
+	}
+}
+
+// Reporter 周期性地将 Tracker 的快照渲染/上报出去
+type Reporter interface {
+	Report(snap Snapshot)
+}
+
+// Run 每隔 interval 拉取一次快照并交给 reporter,直到 done 被关闭
+func Run(t *Tracker, reporter Reporter, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			reporter.Report(t.Snapshot())
+		case <-done:
+			reporter.Report(t.Snapshot())
+			return
+		}
+	}
+}
+
+// ConsoleReporter 是一个手写的、无第三方依赖的多行 ANSI 进度渲染器
+type ConsoleReporter struct {
+	out       io.Writer
+	lastLines int
+}
+
+// NewConsoleReporter 创建一个向 out 渲染进度的 ConsoleReporter
+func NewConsoleReporter(out io.Writer) *ConsoleReporter {
+	return &ConsoleReporter{out: out}
+}
+
+// Report 用 ANSI 光标移动把上一次渲染的行数清空后重绘
+func (c *ConsoleReporter) Report(snap Snapshot) {
+	if c.lastLines > 0 {
+		fmt.Fprintf(c.out, "\x1b[%dA\x1b[J", c.lastLines)
+	}
+	lines := 1
+	fmt.Fprintf(c.out, "total: %s/%s  speed: %s/s  eta: %s\n",
+		humanBytes(snap.BytesDone), humanBytes(snap.BytesTotal), humanBytes(int64(snap.SpeedBps)), snap.ETA.Round(time.Second))
+	for _, s := range snap.Slices {
+		fmt.Fprintf(c.out, "  slice[%d]: %s/%s\n", s.Num, humanBytes(s.Done), humanBytes(s.Total))
+		lines++
+	}
+	c.lastLines = lines
+}
+
+// humanBytes 把字节数格式化成带单位的可读字符串
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for n/div >= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// JSONReporter 把每次快照编码成一行 NDJSON,便于脚本消费
+type JSONReporter struct {
+	out io.Writer
+}
+
+// NewJSONReporter 创建一个向 out 写 NDJSON 事件的 JSONReporter
+func NewJSONReporter(out io.Writer) *JSONReporter {
+	return &JSONReporter{out: out}
+}
+
+// Report 写出一行 NDJSON
+func (j *JSONReporter) Report(snap Snapshot) {
+	fmt.Fprintf(j.out, `{"bytes_done":%d,"bytes_total":%d,"speed_bytes_per_sec":%.2f,"eta_seconds":%.0f}`+"\n",
+		snap.BytesDone, snap.BytesTotal, snap.SpeedBps, snap.ETA.Seconds())
+}